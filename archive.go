@@ -0,0 +1,257 @@
+package afero
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ArchiveFormat identifies the container format read by ExtractArchive.
+type ArchiveFormat int
+
+const (
+	ArchiveTar ArchiveFormat = iota
+	ArchiveTarGz
+	ArchiveZip
+)
+
+var ErrUnknownArchiveFormat = errors.New("unknown archive format")
+
+// ExtractArchive unpacks the archive read from r into dstFs below dstPrefix.
+// Directories and regular files are recreated with the mode and modification
+// time recorded in the archive; other entry types are skipped. Directory
+// attributes are applied last so that extracting their children does not
+// disturb them. Entry names are cleaned and rooted at dstPrefix, so entries
+// can never be written outside it.
+//
+// Tar archives are streamed entry by entry. Zip archives need random access to
+// their central directory: if r is an io.ReaderAt with a known size, such as
+// a File opened on a regular file, entries are read from it directly;
+// otherwise r is read fully into memory first.
+func (a Afero) ExtractArchive(r io.Reader, format ArchiveFormat, dstPrefix string) error {
+	return ExtractArchive(r, format, a.Fs, dstPrefix)
+}
+
+func ExtractArchive(r io.Reader, format ArchiveFormat, dstFs Fs, dstPrefix string) error {
+	switch format {
+	case ArchiveTar:
+		return extractTar(r, dstFs, dstPrefix)
+	case ArchiveTarGz:
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		return extractTar(gz, dstFs, dstPrefix)
+	case ArchiveZip:
+		return extractZip(r, dstFs, dstPrefix)
+	}
+	return ErrUnknownArchiveFormat
+}
+
+// ZipReadCloser is a zip.Reader reading from a File that must be closed
+// once the archive is no longer needed.
+type ZipReadCloser struct {
+	*zip.Reader
+	f File
+}
+
+// Close closes the underlying File.
+func (z *ZipReadCloser) Close() error {
+	return z.f.Close()
+}
+
+// OpenZip opens the zip archive stored at name. Entries are read on demand
+// through the File's ReadAt, so only the central directory and the entries
+// actually opened are read.
+func (a Afero) OpenZip(name string) (*ZipReadCloser, error) {
+	return OpenZip(a.Fs, name)
+}
+
+func OpenZip(fs Fs, name string) (*ZipReadCloser, error) {
+	f, err := fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	zr, err := zip.NewReader(f, fi.Size())
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &ZipReadCloser{Reader: zr, f: f}, nil
+}
+
+// archiveDir records a directory whose mode and times are applied once
+// all archive entries have been extracted.
+type archiveDir struct {
+	name  string
+	mode  os.FileMode
+	mtime time.Time
+}
+
+func extractTar(r io.Reader, dstFs Fs, dstPrefix string) error {
+	var dirs []archiveDir
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return finishDirs(dstFs, dirs)
+		}
+		if err != nil {
+			return err
+		}
+
+		name := archiveEntryPath(dstPrefix, hdr.Name)
+		mode := hdr.FileInfo().Mode()
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			dirs = append(dirs, archiveDir{name, mode, hdr.ModTime})
+			err = dstFs.MkdirAll(name, 0777)
+		case tar.TypeReg:
+			err = extractFile(dstFs, name, mode, hdr.ModTime, tr)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func extractZip(r io.Reader, dstFs Fs, dstPrefix string) error {
+	ra, size, err := zipReaderAt(r)
+	if err != nil {
+		return err
+	}
+	zr, err := zip.NewReader(ra, size)
+	if err != nil {
+		return err
+	}
+
+	var dirs []archiveDir
+	for _, zf := range zr.File {
+		name := archiveEntryPath(dstPrefix, zf.Name)
+		mode := zf.Mode()
+		switch {
+		case mode.IsDir():
+			dirs = append(dirs, archiveDir{name, mode, zf.Modified})
+			err = dstFs.MkdirAll(name, 0777)
+		case mode.IsRegular():
+			var rc io.ReadCloser
+			rc, err = zf.Open()
+			if err != nil {
+				return err
+			}
+			err = extractFile(dstFs, name, mode, zf.Modified, rc)
+			rc.Close()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return finishDirs(dstFs, dirs)
+}
+
+// zipReaderAt returns r itself when it supports random access and knows its
+// size, as *bytes.Reader, *io.SectionReader and Files backed by regular
+// files do. Anything else, such as a pipe, is read into memory.
+func zipReaderAt(r io.Reader) (io.ReaderAt, int64, error) {
+	if ra, ok := r.(io.ReaderAt); ok {
+		switch sr := r.(type) {
+		case interface{ Size() int64 }:
+			return ra, sr.Size(), nil
+		case interface{ Stat() (os.FileInfo, error) }:
+			fi, err := sr.Stat()
+			if err != nil {
+				return nil, 0, err
+			}
+			if fi.Mode().IsRegular() && canReadAt(ra, fi.Size()) {
+				return ra, fi.Size(), nil
+			}
+		}
+	}
+	data, err := ReadAll(r)
+	if err != nil {
+		return nil, 0, err
+	}
+	return bytes.NewReader(data), int64(len(data)), nil
+}
+
+// canReadAt reports whether ra can return the last byte of a size byte
+// file. Not every File implements ReadAt (sftpfs returns 0, nil), so this
+// is checked before relying on it.
+func canReadAt(ra io.ReaderAt, size int64) bool {
+	if size <= 0 {
+		return false
+	}
+	var b [1]byte
+	n, err := ra.ReadAt(b[:], size-1)
+	return n == 1 && (err == nil || err == io.EOF)
+}
+
+// archiveEntryPath maps an archive entry name below prefix. The name is
+// rooted before cleaning so that ".." elements cannot climb above prefix,
+// and made relative again so that an empty prefix means the current
+// directory rather than the filesystem root.
+func archiveEntryPath(prefix, name string) string {
+	sep := string(filepath.Separator)
+	name = filepath.Clean(sep + filepath.FromSlash(name))
+	name = strings.TrimPrefix(name, sep)
+	if prefix == "" {
+		prefix = "."
+	}
+	return filepath.Join(prefix, name)
+}
+
+func finishDirs(fs Fs, dirs []archiveDir) error {
+	// Archives list parents before children, so walk them in reverse to
+	// avoid locking down a read-only parent before its children are done.
+	for i := len(dirs) - 1; i >= 0; i-- {
+		d := dirs[i]
+		if err := fs.Chmod(d.name, d.mode.Perm()); err != nil {
+			return err
+		}
+		if err := fs.Chtimes(d.name, d.mtime, d.mtime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extractFile(fs Fs, name string, mode os.FileMode, mtime time.Time, r io.Reader) error {
+	if err := fs.MkdirAll(filepath.Dir(name), 0777); err != nil {
+		return err
+	}
+	// A file left by an earlier extraction may be read-only, so replace it
+	// rather than opening it for writing.
+	if fi, err := fs.Stat(name); err == nil && fi.Mode().IsRegular() {
+		if err := fs.Remove(name); err != nil {
+			return err
+		}
+	}
+	f, err := fs.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode.Perm())
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+	if err != nil {
+		return err
+	}
+	if err := fs.Chmod(name, mode.Perm()); err != nil {
+		return err
+	}
+	return fs.Chtimes(name, mtime, mtime)
+}
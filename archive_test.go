@@ -0,0 +1,343 @@
+package afero
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var archiveTestTime = time.Date(2020, 3, 4, 5, 6, 7, 0, time.UTC)
+
+type archiveTestEntry struct {
+	name    string
+	content string
+	mode    os.FileMode
+}
+
+var archiveTestEntries = []archiveTestEntry{
+	{"dir/", "", os.ModeDir | 0750},
+	{"dir/a.txt", "aaa", 0640},
+	{"dir/sub/b.txt", "bbbb", 0600},
+	{"../escape.txt", "nope", 0644},
+}
+
+func makeTestTar(t *testing.T) []byte {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range archiveTestEntries {
+		hdr := &tar.Header{
+			Name:    e.name,
+			Mode:    int64(e.mode.Perm()),
+			Size:    int64(len(e.content)),
+			ModTime: archiveTestTime,
+		}
+		if e.mode.IsDir() {
+			hdr.Typeflag = tar.TypeDir
+		} else {
+			hdr.Typeflag = tar.TypeReg
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func makeTestZip(t *testing.T) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range archiveTestEntries {
+		hdr := &zip.FileHeader{Name: e.name, Modified: archiveTestTime}
+		hdr.SetMode(e.mode)
+		w, err := zw.CreateHeader(hdr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(e.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestExtractArchive(t *testing.T) {
+	tarData := makeTestTar(t)
+	var gzBuf bytes.Buffer
+	gz := gzip.NewWriter(&gzBuf)
+	gz.Write(tarData)
+	gz.Close()
+
+	archives := map[string]struct {
+		format ArchiveFormat
+		data   []byte
+	}{
+		"tar":    {ArchiveTar, tarData},
+		"tar.gz": {ArchiveTarGz, gzBuf.Bytes()},
+		"zip":    {ArchiveZip, makeTestZip(t)},
+	}
+
+	for name, a := range archives {
+		fs := NewMemMapFs()
+		prefix := filepath.FromSlash("/out")
+		if err := ExtractArchive(bytes.NewReader(a.data), a.format, fs, prefix); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		for _, e := range archiveTestEntries {
+			path := filepath.Join(prefix, filepath.FromSlash(e.name))
+			if e.name == "../escape.txt" {
+				path = filepath.Join(prefix, "escape.txt")
+				if _, err := fs.Stat(filepath.FromSlash("/escape.txt")); !os.IsNotExist(err) {
+					t.Errorf("%s: entry escaped the destination prefix", name)
+				}
+			}
+			fi, err := fs.Stat(path)
+			if err != nil {
+				t.Errorf("%s: %v", name, err)
+				continue
+			}
+			if fi.Mode().Perm() != e.mode.Perm() {
+				t.Errorf("%s: %s: mode %v, expected %v", name, path, fi.Mode().Perm(), e.mode.Perm())
+			}
+			if !fi.ModTime().Equal(archiveTestTime) {
+				t.Errorf("%s: %s: mtime %v, expected %v", name, path, fi.ModTime(), archiveTestTime)
+			}
+			if e.mode.IsDir() {
+				continue
+			}
+			b, err := ReadFile(fs, path)
+			if err != nil {
+				t.Errorf("%s: %v", name, err)
+			} else if string(b) != e.content {
+				t.Errorf("%s: %s: content %q, expected %q", name, path, b, e.content)
+			}
+		}
+	}
+}
+
+func TestExtractArchiveUnknownFormat(t *testing.T) {
+	err := ExtractArchive(bytes.NewReader(nil), ArchiveFormat(-1), NewMemMapFs(), "/")
+	if err != ErrUnknownArchiveFormat {
+		t.Errorf("expected ErrUnknownArchiveFormat, got %v", err)
+	}
+}
+
+func TestOpenZip(t *testing.T) {
+	fs := NewMemMapFs()
+	name := filepath.FromSlash("/archive.zip")
+	if err := WriteFile(fs, name, makeTestZip(t), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := OpenZip(fs, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+
+	if len(zr.File) != len(archiveTestEntries) {
+		t.Fatalf("got %d entries, expected %d", len(zr.File), len(archiveTestEntries))
+	}
+	for i, zf := range zr.File {
+		e := archiveTestEntries[i]
+		if zf.Name != e.name {
+			t.Errorf("entry %d: name %q, expected %q", i, zf.Name, e.name)
+		}
+		if e.mode.IsDir() {
+			continue
+		}
+		rc, err := zf.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != e.content {
+			t.Errorf("entry %q: content %q, expected %q", zf.Name, b, e.content)
+		}
+	}
+
+	if _, err := OpenZip(fs, filepath.FromSlash("/missing.zip")); !os.IsNotExist(err) {
+		t.Errorf("expected not-exist error for a missing archive, got %v", err)
+	}
+}
+
+func TestExtractArchiveEmptyPrefix(t *testing.T) {
+	fs := NewMemMapFs()
+	if err := ExtractArchive(bytes.NewReader(makeTestTar(t)), ArchiveTar, fs, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	rel := filepath.FromSlash("dir/a.txt")
+	if _, err := fs.Stat(rel); err != nil {
+		t.Errorf("expected %s relative to the current directory: %v", rel, err)
+	}
+	abs := filepath.FromSlash("/dir/a.txt")
+	if _, err := fs.Stat(abs); !os.IsNotExist(err) {
+		t.Errorf("%s was extracted relative to the filesystem root", abs)
+	}
+}
+
+// readerAtOnly fails sequential reads, so extraction can only succeed
+// through ReadAt.
+type readerAtOnly struct {
+	*bytes.Reader
+}
+
+func (r readerAtOnly) Read(p []byte) (int, error) {
+	return 0, errors.New("unexpected sequential read")
+}
+
+func TestExtractArchiveZipReaderAt(t *testing.T) {
+	data := makeTestZip(t)
+	src := NewMemMapFs()
+	name := filepath.FromSlash("/archive.zip")
+	if err := WriteFile(src, name, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := src.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	for desc, r := range map[string]io.Reader{
+		"File":         f,
+		"Size() int64": readerAtOnly{bytes.NewReader(data)},
+	} {
+		fs := NewMemMapFs()
+		if err := ExtractArchive(r, ArchiveZip, fs, "/out"); err != nil {
+			t.Errorf("%s: %v", desc, err)
+			continue
+		}
+		b, err := ReadFile(fs, filepath.FromSlash("/out/dir/sub/b.txt"))
+		if err != nil {
+			t.Errorf("%s: %v", desc, err)
+		} else if string(b) != "bbbb" {
+			t.Errorf("%s: content %q, expected %q", desc, b, "bbbb")
+		}
+	}
+}
+
+// stubReadAtFile mimics Files whose ReadAt is not implemented, such as
+// sftpfs.File.
+type stubReadAtFile struct {
+	File
+}
+
+func (f stubReadAtFile) ReadAt(p []byte, off int64) (int, error) {
+	return 0, nil
+}
+
+func TestExtractArchiveZipStream(t *testing.T) {
+	data := makeTestZip(t)
+
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pr.Close()
+	go func() {
+		pw.Write(data)
+		pw.Close()
+	}()
+
+	src := NewMemMapFs()
+	name := filepath.FromSlash("/archive.zip")
+	if err := WriteFile(src, name, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := src.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	for desc, r := range map[string]io.Reader{
+		"os.Pipe":     pr,
+		"stub ReadAt": stubReadAtFile{f},
+	} {
+		fs := NewMemMapFs()
+		if err := ExtractArchive(r, ArchiveZip, fs, "/out"); err != nil {
+			t.Errorf("%s: %v", desc, err)
+			continue
+		}
+		b, err := ReadFile(fs, filepath.FromSlash("/out/dir/sub/b.txt"))
+		if err != nil {
+			t.Errorf("%s: %v", desc, err)
+		} else if string(b) != "bbbb" {
+			t.Errorf("%s: content %q, expected %q", desc, b, "bbbb")
+		}
+	}
+}
+
+// permFs refuses to open files for writing that their mode marks read-only,
+// as OsFs does for unprivileged users.
+type permFs struct {
+	Fs
+}
+
+func (p permFs) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR) != 0 {
+		if fi, err := p.Fs.Stat(name); err == nil && fi.Mode().Perm()&0200 == 0 {
+			return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrPermission}
+		}
+	}
+	return p.Fs.OpenFile(name, flag, perm)
+}
+
+func TestExtractArchiveTwiceReadOnly(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	content := "read only"
+	hdr := &tar.Header{
+		Name:     "ro.txt",
+		Mode:     0444,
+		Size:     int64(len(content)),
+		ModTime:  archiveTestTime,
+		Typeflag: tar.TypeReg,
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		t.Fatal(err)
+	}
+	tw.Write([]byte(content))
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	fs := permFs{NewMemMapFs()}
+	dir := filepath.FromSlash("/out")
+	for i := 0; i < 2; i++ {
+		if err := ExtractArchive(bytes.NewReader(buf.Bytes()), ArchiveTar, fs, dir); err != nil {
+			t.Fatalf("extraction %d: %v", i+1, err)
+		}
+	}
+	name := filepath.Join(dir, "ro.txt")
+	if b, err := ReadFile(fs, name); err != nil || string(b) != content {
+		t.Errorf("got %q, %v, expected %q", b, err, content)
+	}
+	if fi, err := fs.Stat(name); err != nil {
+		t.Error(err)
+	} else if fi.Mode().Perm() != 0444 {
+		t.Errorf("mode %v, expected %v", fi.Mode().Perm(), os.FileMode(0444))
+	}
+}
@@ -94,6 +94,10 @@ func (b *BasePathFs) Name() string {
 	return "BasePathFs"
 }
 
+func (b *BasePathFs) Capabilities() Features {
+	return CapabilitiesOf(b.source)
+}
+
 func (b *BasePathFs) Stat(name string) (fi os.FileInfo, err error) {
 	if name, err = b.RealPath(name); err != nil {
 		return nil, &os.PathError{Op: "stat", Path: name, Err: err}
@@ -287,6 +287,13 @@ func (u *CacheOnReadFs) Name() string {
 	return "CacheOnReadFs"
 }
 
+// Capabilities reports the features shared by both layers, as writes go to
+// each of them. Renames touch both layers in turn, so they are never atomic.
+func (u *CacheOnReadFs) Capabilities() Features {
+	f := CapabilitiesOf(u.base) & CapabilitiesOf(u.layer)
+	return f &^ (FeatureAtomicRename | FeatureSymlinks)
+}
+
 func (u *CacheOnReadFs) MkdirAll(name string, perm os.FileMode) error {
 	err := u.base.MkdirAll(name, perm)
 	if err != nil {
@@ -0,0 +1,47 @@
+package afero
+
+// Features is a set of optional filesystem features.
+type Features uint
+
+const (
+	// FeatureWrite means files and directories can be created and modified.
+	FeatureWrite Features = 1 << iota
+
+	// FeatureChtimes means Chtimes sets modification times as requested.
+	FeatureChtimes
+
+	// FeatureAtomicRename means Rename replaces the target in one step,
+	// so readers never observe a missing or partially written file.
+	FeatureAtomicRename
+
+	// FeatureSymlinks means symbolic links can be created and read through
+	// the Symlinker interface.
+	FeatureSymlinks
+
+	// FeatureVersioning means the backend keeps earlier versions of files.
+	FeatureVersioning
+
+	// FeatureLocking means the backend offers file locking between writers.
+	FeatureLocking
+)
+
+// Has reports whether all features in other are present in f.
+func (f Features) Has(other Features) bool {
+	return f&other == other
+}
+
+// Capability is implemented by filesystems that can report which optional
+// features they support, so generic code can adapt up front instead of
+// probing for errors.
+type Capability interface {
+	Capabilities() Features
+}
+
+// CapabilitiesOf returns the features supported by fs. Filesystems that do
+// not implement Capability report no optional features.
+func CapabilitiesOf(fs Fs) Features {
+	if c, ok := fs.(Capability); ok {
+		return c.Capabilities()
+	}
+	return 0
+}
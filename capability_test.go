@@ -0,0 +1,50 @@
+package afero
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestCapabilitiesOf(t *testing.T) {
+	osFs := &OsFs{}
+	memFs := &MemMapFs{}
+
+	tests := []struct {
+		name string
+		fs   Fs
+		want Features
+	}{
+		{"OsFs", osFs, FeatureWrite | FeatureChtimes | FeatureAtomicRename | FeatureSymlinks},
+		{"MemMapFs", memFs, FeatureWrite | FeatureChtimes | FeatureAtomicRename},
+		{"BasePathFs", NewBasePathFs(osFs, "/tmp"), CapabilitiesOf(osFs)},
+		{"RegexpFs", NewRegexpFs(osFs, regexp.MustCompile(`\.txt$`)), FeatureWrite | FeatureChtimes | FeatureAtomicRename},
+		{"ReadOnlyFs", NewReadOnlyFs(osFs), 0},
+		{"CopyOnWriteFs", NewCopyOnWriteFs(NewReadOnlyFs(osFs), memFs), FeatureWrite | FeatureChtimes},
+		{"CopyOnWriteFs over OsFs", NewCopyOnWriteFs(NewReadOnlyFs(osFs), osFs), FeatureWrite | FeatureChtimes | FeatureSymlinks},
+		{"CacheOnReadFs", NewCacheOnReadFs(osFs, memFs, 0), FeatureWrite | FeatureChtimes},
+	}
+
+	for _, tt := range tests {
+		got := CapabilitiesOf(tt.fs)
+		if got != tt.want {
+			t.Errorf("%s: CapabilitiesOf() = %b, want %b", tt.name, got, tt.want)
+		}
+		// Reported features must hold up without probing.
+		if _, ok := tt.fs.(Symlinker); got.Has(FeatureSymlinks) && !ok {
+			t.Errorf("%s: reports FeatureSymlinks but does not implement Symlinker", tt.name)
+		}
+	}
+}
+
+func TestFeaturesHas(t *testing.T) {
+	f := FeatureWrite | FeatureChtimes
+	if !f.Has(FeatureWrite) {
+		t.Error("expected FeatureWrite to be present")
+	}
+	if !f.Has(FeatureWrite | FeatureChtimes) {
+		t.Error("expected FeatureWrite|FeatureChtimes to be present")
+	}
+	if f.Has(FeatureWrite | FeatureSymlinks) {
+		t.Error("did not expect FeatureSymlinks to be present")
+	}
+}
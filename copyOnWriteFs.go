@@ -309,6 +309,12 @@ func (u *CopyOnWriteFs) Name() string {
 	return "CopyOnWriteFs"
 }
 
+// Capabilities reports the features of the writable layer. Renames may have
+// to copy a file up from the base first, so they are never atomic.
+func (u *CopyOnWriteFs) Capabilities() Features {
+	return CapabilitiesOf(u.layer) &^ (FeatureAtomicRename | FeatureVersioning | FeatureLocking)
+}
+
 func (u *CopyOnWriteFs) MkdirAll(name string, perm os.FileMode) error {
 	dir, err := IsDir(u.base, name)
 	if err != nil {
@@ -137,6 +137,76 @@ func WriteFile(fs Fs, filename string, data []byte, perm os.FileMode) error {
 	return err
 }
 
+// AtomicWriteOptions configures WriteFileAtomic.
+type AtomicWriteOptions struct {
+	// Perm is the mode of the resulting file. Zero keeps the mode of the
+	// file being replaced, or creates a new file with 0644 subject to the
+	// umask.
+	Perm os.FileMode
+
+	// Sync flushes the temporary file to stable storage before it is
+	// renamed into place.
+	Sync bool
+}
+
+// WriteFileAtomic writes the contents of r to a temporary file next to
+// filename and renames it over filename only once everything has been
+// written. Readers of filename therefore see either the old or the new
+// contents, never a partial write. The temporary file is removed on failure.
+// On backends whose Rename is not atomic the guarantee is only as strong as
+// their Rename.
+func (a Afero) WriteFileAtomic(filename string, r io.Reader, opts AtomicWriteOptions) error {
+	return WriteFileAtomic(a.Fs, filename, r, opts)
+}
+
+func WriteFileAtomic(fs Fs, filename string, r io.Reader, opts AtomicWriteOptions) (err error) {
+	// Chmod bypasses the umask, so it is only used to apply a mode that was
+	// asked for or already present on filename, never the default.
+	perm, chmod := opts.Perm, opts.Perm != 0
+	if perm == 0 {
+		perm = 0644
+		if fi, err := fs.Stat(filename); err == nil {
+			perm, chmod = fi.Mode().Perm(), true
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	dir, base := filepath.Split(filename)
+	if dir == "" {
+		// TempFile would fall back to os.TempDir, which may not be on the
+		// same device as filename.
+		dir = "."
+	}
+	f, err := tempFile(fs, dir, "."+base+".tmp*", perm)
+	if err != nil {
+		return err
+	}
+	tmpname := f.Name()
+	defer func() {
+		if err != nil {
+			fs.Remove(tmpname)
+		}
+	}()
+
+	_, err = io.Copy(f, r)
+	if err == nil && opts.Sync {
+		err = f.Sync()
+	}
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+	if err != nil {
+		return err
+	}
+	if chmod {
+		if err = fs.Chmod(tmpname, perm); err != nil {
+			return err
+		}
+	}
+	return fs.Rename(tmpname, filename)
+}
+
 // Random number state.
 // We generate random temporary file names so that there's a good
 // chance the file doesn't exist yet - keeps the number of tries in
@@ -176,6 +246,10 @@ func (a Afero) TempFile(dir, pattern string) (f File, err error) {
 }
 
 func TempFile(fs Fs, dir, pattern string) (f File, err error) {
+	return tempFile(fs, dir, pattern, 0600)
+}
+
+func tempFile(fs Fs, dir, pattern string, perm os.FileMode) (f File, err error) {
 	if dir == "" {
 		dir = os.TempDir()
 	}
@@ -190,7 +264,7 @@ func TempFile(fs Fs, dir, pattern string) (f File, err error) {
 	nconflict := 0
 	for i := 0; i < 10000; i++ {
 		name := filepath.Join(dir, prefix+nextRandom()+suffix)
-		f, err = fs.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+		f, err = fs.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, perm)
 		if os.IsExist(err) {
 			if nconflict++; nconflict > 10 {
 				randmu.Lock()
@@ -16,7 +16,12 @@
 package afero
 
 import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
 )
@@ -174,3 +179,117 @@ func TestTempFile(t *testing.T) {
 		})
 	}
 }
+
+func TestWriteFileAtomic(t *testing.T) {
+	testFS = &MemMapFs{}
+	fsutil := &Afero{Fs: testFS}
+	dir := filepath.FromSlash("/atomic")
+	filename := filepath.Join(dir, "file.txt")
+	if err := fsutil.WriteFile(filename, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := fsutil.WriteFileAtomic(filename, strings.NewReader("new"), AtomicWriteOptions{Perm: 0640, Sync: true})
+	if err != nil {
+		t.Fatalf("WriteFileAtomic %s: %v", filename, err)
+	}
+	contents, err := fsutil.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("ReadFile %s: %v", filename, err)
+	}
+	if string(contents) != "new" {
+		t.Fatalf("contents = %q, expected %q", contents, "new")
+	}
+	fi, err := testFS.Stat(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0640 {
+		t.Errorf("mode = %v, expected %v", fi.Mode().Perm(), os.FileMode(0640))
+	}
+
+	list, err := ReadDir(testFS, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 {
+		t.Errorf("ReadDir %s: expected only the target file, got %d entries", dir, len(list))
+	}
+}
+
+func TestWriteFileAtomicFailure(t *testing.T) {
+	testFS = &MemMapFs{}
+	dir := filepath.FromSlash("/atomic")
+	filename := filepath.Join(dir, "file.txt")
+	if err := WriteFile(testFS, filename, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	readErr := errors.New("read failed")
+	r := io.MultiReader(strings.NewReader("partial"), &errReader{readErr})
+	if err := WriteFileAtomic(testFS, filename, r, AtomicWriteOptions{}); err != readErr {
+		t.Fatalf("WriteFileAtomic: expected %v, got %v", readErr, err)
+	}
+
+	contents, err := ReadFile(testFS, filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(contents) != "old" {
+		t.Errorf("contents = %q, expected the original %q", contents, "old")
+	}
+	list, err := ReadDir(testFS, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 {
+		t.Errorf("ReadDir %s: temporary file was not removed, got %d entries", dir, len(list))
+	}
+}
+
+func TestWriteFileAtomicDefaultMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("skipping permission bit checks on windows")
+	}
+	dir, err := ioutil.TempDir("", "afero-atomic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fs := NewOsFs()
+
+	// A new file must not end up group or world writable.
+	filename := filepath.Join(dir, "new.txt")
+	if err := WriteFileAtomic(fs, filename, strings.NewReader("new"), AtomicWriteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := fs.Stat(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := fi.Mode().Perm(); perm&0022 != 0 || perm&0600 != 0600 {
+		t.Errorf("new file mode = %v, expected 0644 narrowed by the umask", perm)
+	}
+
+	// An existing file keeps its mode.
+	filename = filepath.Join(dir, "existing.txt")
+	if err := WriteFile(fs, filename, []byte("old"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Chmod(filename, 0640); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFileAtomic(fs, filename, strings.NewReader("new"), AtomicWriteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err = fs.Stat(filename); err != nil {
+		t.Fatal(err)
+	}
+	if perm := fi.Mode().Perm(); perm != 0640 {
+		t.Errorf("replaced file mode = %v, expected %v", perm, os.FileMode(0640))
+	}
+}
+
+type errReader struct{ err error }
+
+func (r *errReader) Read(p []byte) (int, error) { return 0, r.err }
@@ -205,11 +205,24 @@ func (f *File) Read(b []byte) (n int, err error) {
 	return
 }
 
+// ReadAt reads from off without touching the handle's offset, so it is safe
+// to call concurrently, as io.ReaderAt consumers such as archive/zip expect.
 func (f *File) ReadAt(b []byte, off int64) (n int, err error) {
-	prev := atomic.LoadInt64(&f.at)
-	atomic.StoreInt64(&f.at, off)
-	n, err = f.Read(b)
-	atomic.StoreInt64(&f.at, prev)
+	f.fileData.Lock()
+	defer f.fileData.Unlock()
+	if f.closed == true {
+		return 0, ErrFileClosed
+	}
+	if off < 0 {
+		return 0, &os.PathError{Op: "readat", Path: f.fileData.name, Err: errors.New("negative offset")}
+	}
+	if off >= int64(len(f.fileData.data)) {
+		return 0, io.EOF
+	}
+	n = copy(b, f.fileData.data[off:])
+	if n < len(b) {
+		err = io.EOF
+	}
 	return
 }
 
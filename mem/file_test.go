@@ -3,6 +3,7 @@ package mem
 import (
 	"bytes"
 	"io"
+	"sync"
 	"testing"
 	"time"
 )
@@ -296,3 +297,40 @@ func TestFileWriteAndSeek(t *testing.T) {
 		assert(cur == off, cur, off)
 	}
 }
+
+func TestFileReadAtConcurrent(t *testing.T) {
+	t.Parallel()
+
+	fd := CreateFile("foo")
+	f := NewFileHandle(fd)
+	data := []byte("0123456789")
+	if _, err := f.Write(data); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < len(data); i++ {
+		wg.Add(1)
+		go func(off int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				b := make([]byte, 1)
+				n, err := f.ReadAt(b, int64(off))
+				if n != 1 || err != nil || b[0] != data[off] {
+					t.Errorf("ReadAt %d: got %d, %q, %v", off, n, b[:n], err)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	b := make([]byte, 4)
+	n, err := f.ReadAt(b, 8)
+	if n != 2 || err != io.EOF {
+		t.Errorf("short ReadAt: expected 2, io.EOF, got %d, %v", n, err)
+	}
+	if _, err := f.ReadAt(b, 20); err != io.EOF {
+		t.Errorf("ReadAt past end: expected io.EOF, got %v", err)
+	}
+}
@@ -51,6 +51,10 @@ func (m *MemMapFs) getData() map[string]*mem.FileData {
 
 func (*MemMapFs) Name() string { return "MemMapFS" }
 
+func (*MemMapFs) Capabilities() Features {
+	return FeatureWrite | FeatureChtimes | FeatureAtomicRename
+}
+
 func (m *MemMapFs) Create(name string) (File, error) {
 	name = normalizePath(name)
 	m.mu.Lock()
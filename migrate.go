@@ -0,0 +1,263 @@
+package afero
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// MigrateOptions configures Migrate.
+type MigrateOptions struct {
+	// SrcRoot is the directory in the source filesystem to migrate.
+	// Defaults to ".".
+	SrcRoot string
+
+	// DstRoot is the directory in the destination filesystem the migrated
+	// files are placed under. Defaults to ".".
+	DstRoot string
+
+	// RewriteName maps the path of a file relative to SrcRoot to its path
+	// relative to DstRoot. Returning the empty string skips the file.
+	// The result is cleaned and rooted at DstRoot, so it cannot place files
+	// outside it. If nil, names are kept as they are.
+	RewriteName func(name string) string
+
+	// Transform, if set, wraps the contents of each file on its way to the
+	// destination, e.g. to recompress it. name is the source-relative path.
+	// If the returned reader is an io.Closer, it is closed once the file
+	// has been copied.
+	Transform func(name string, r io.Reader) (io.Reader, error)
+
+	// BytesPerSecond limits the overall read rate from the source.
+	// Zero means unlimited.
+	BytesPerSecond int64
+
+	// CheckpointFile, if set, names a file in CheckpointFs that records
+	// every completed source path. Files already listed there are skipped,
+	// so an interrupted migration can be resumed by running it again.
+	CheckpointFile string
+
+	// CheckpointFs holds CheckpointFile. Defaults to the OS filesystem.
+	CheckpointFs Fs
+
+	// Verify re-reads each file right after it has been written and checks
+	// it against what was written. A file is only recorded in the checkpoint
+	// once it has passed, so resumed runs never skip an unverified file.
+	Verify bool
+}
+
+// Migrate copies every regular file below opts.SrcRoot in srcFs to dstFs,
+// optionally renaming and transforming it along the way. File modes are kept,
+// and so are modification times when dstFs reports FeatureChtimes.
+// Directories are created as needed; empty directories are not migrated.
+func (a Afero) Migrate(dstFs Fs, opts MigrateOptions) error {
+	return Migrate(a.Fs, dstFs, opts)
+}
+
+func Migrate(srcFs, dstFs Fs, opts MigrateOptions) (err error) {
+	if opts.SrcRoot == "" {
+		opts.SrcRoot = "."
+	}
+	if opts.DstRoot == "" {
+		opts.DstRoot = "."
+	}
+	if opts.CheckpointFs == nil {
+		opts.CheckpointFs = NewOsFs()
+	}
+
+	var done map[string]bool
+	var checkpoint File
+	if opts.CheckpointFile != "" {
+		done, err = readMigrateCheckpoint(opts.CheckpointFs, opts.CheckpointFile)
+		if err != nil {
+			return err
+		}
+		checkpoint, err = opts.CheckpointFs.OpenFile(opts.CheckpointFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err1 := checkpoint.Close(); err == nil {
+				err = err1
+			}
+		}()
+	}
+
+	var limiter *rateLimiter
+	if opts.BytesPerSecond > 0 {
+		limiter = &rateLimiter{rate: opts.BytesPerSecond, start: time.Now()}
+	}
+	keepTimes := CapabilitiesOf(dstFs).Has(FeatureChtimes)
+
+	err = Walk(srcFs, opts.SrcRoot, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		name, err := filepath.Rel(opts.SrcRoot, path)
+		if err != nil {
+			return err
+		}
+		if done[name] {
+			return nil
+		}
+		dstName := name
+		if opts.RewriteName != nil {
+			if dstName = opts.RewriteName(name); dstName == "" {
+				return nil
+			}
+		}
+		dstPath := archiveEntryPath(opts.DstRoot, dstName)
+
+		sum, err := migrateFile(srcFs, dstFs, path, dstPath, name, info, opts.Transform, limiter)
+		if err != nil {
+			return err
+		}
+		if keepTimes {
+			if err := dstFs.Chtimes(dstPath, info.ModTime(), info.ModTime()); err != nil {
+				return err
+			}
+		}
+		if opts.Verify {
+			got, err := migrateChecksum(dstFs, dstPath)
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(got, sum) {
+				return fmt.Errorf("migrate: verification of %s failed: content differs from what was written", dstPath)
+			}
+		}
+		if checkpoint != nil {
+			if _, err := checkpoint.WriteString(name + "\n"); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return err
+}
+
+// migrateFile copies a single file and returns the SHA-256 of the bytes
+// written to the destination.
+func migrateFile(srcFs, dstFs Fs, srcPath, dstPath, name string, info os.FileInfo,
+	transform func(string, io.Reader) (io.Reader, error), limiter *rateLimiter) ([]byte, error) {
+	src, err := srcFs.Open(srcPath)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	var r io.Reader = src
+	if limiter != nil {
+		r = &rateLimitedReader{r: r, l: limiter}
+	}
+	if transform != nil {
+		if r, err = transform(name, r); err != nil {
+			return nil, err
+		}
+		if c, ok := r.(io.Closer); ok {
+			defer c.Close()
+		}
+	}
+
+	if err := dstFs.MkdirAll(filepath.Dir(dstPath), 0777); err != nil {
+		return nil, err
+	}
+	dst, err := dstFs.OpenFile(dstPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return nil, err
+	}
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(dst, h), r)
+	if err1 := dst.Close(); err == nil {
+		err = err1
+	}
+	if err != nil {
+		return nil, err
+	}
+	// OpenFile only applies the mode to newly created files.
+	if err := dstFs.Chmod(dstPath, info.Mode().Perm()); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+func migrateChecksum(fs Fs, name string) ([]byte, error) {
+	f, err := fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+func readMigrateCheckpoint(fs Fs, name string) (map[string]bool, error) {
+	done := make(map[string]bool)
+	f, err := fs.Open(name)
+	if os.IsNotExist(err) {
+		return done, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		if line := strings.TrimSpace(s.Text()); line != "" {
+			done[line] = true
+		}
+	}
+	return done, s.Err()
+}
+
+// rateLimiter paces reads so that the total number of bytes read since
+// start does not exceed rate bytes per second.
+type rateLimiter struct {
+	rate  int64
+	start time.Time
+	total int64
+}
+
+func (l *rateLimiter) wait(n int) {
+	l.total += int64(n)
+	due := l.due()
+	if elapsed := time.Since(l.start); elapsed < due {
+		time.Sleep(due - elapsed)
+	}
+}
+
+// due returns how long after start the bytes read so far may complete.
+// It is computed in floating point, as total * time.Second overflows an
+// int64 after roughly 9.2 GB.
+func (l *rateLimiter) due() time.Duration {
+	return time.Duration(float64(l.total) / float64(l.rate) * float64(time.Second))
+}
+
+type rateLimitedReader struct {
+	r io.Reader
+	l *rateLimiter
+}
+
+func (r *rateLimitedReader) Read(p []byte) (int, error) {
+	// Keep single reads to at most one second's worth of data so the pace
+	// stays smooth.
+	if int64(len(p)) > r.l.rate {
+		p = p[:r.l.rate]
+	}
+	n, err := r.r.Read(p)
+	r.l.wait(n)
+	return n, err
+}
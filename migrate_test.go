@@ -0,0 +1,295 @@
+package afero
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func setupMigrateSource(t *testing.T) (Fs, time.Time) {
+	src := NewMemMapFs()
+	mtime := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+	files := map[string]string{
+		"/src/a.txt":       "alpha",
+		"/src/sub/b.txt":   "bravo",
+		"/src/sub/c.log":   "charlie",
+		"/src/skip/d.txt":  "delta",
+		"/src/done/e.txt":  "echo",
+		"/src/sub/deep/f":  "foxtrot",
+		"/src/sub/deep/g2": "golf",
+	}
+	for name, content := range files {
+		name = filepath.FromSlash(name)
+		if err := WriteReader(src, name, strings.NewReader(content)); err != nil {
+			t.Fatal(err)
+		}
+		if err := src.Chtimes(name, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return src, mtime
+}
+
+func TestMigrate(t *testing.T) {
+	src, mtime := setupMigrateSource(t)
+	dst := NewMemMapFs()
+	state := NewMemMapFs()
+
+	checkpoint := filepath.FromSlash("/migrate.state")
+	if err := WriteFile(state, checkpoint, []byte(filepath.FromSlash("done/e.txt")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := MigrateOptions{
+		SrcRoot: filepath.FromSlash("/src"),
+		DstRoot: filepath.FromSlash("/dst"),
+		RewriteName: func(name string) string {
+			if strings.HasPrefix(name, "skip") {
+				return ""
+			}
+			return filepath.Join("moved", name)
+		},
+		Transform: func(name string, r io.Reader) (io.Reader, error) {
+			b, err := ReadAll(r)
+			if err != nil {
+				return nil, err
+			}
+			return bytes.NewReader(bytes.ToUpper(b)), nil
+		},
+		BytesPerSecond: 1 << 20,
+		CheckpointFile: checkpoint,
+		CheckpointFs:   state,
+		Verify:         true,
+	}
+	if err := Migrate(src, dst, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"/dst/moved/a.txt":       "ALPHA",
+		"/dst/moved/sub/b.txt":   "BRAVO",
+		"/dst/moved/sub/c.log":   "CHARLIE",
+		"/dst/moved/sub/deep/f":  "FOXTROT",
+		"/dst/moved/sub/deep/g2": "GOLF",
+	}
+	for name, content := range expected {
+		name = filepath.FromSlash(name)
+		b, err := ReadFile(dst, name)
+		if err != nil {
+			t.Errorf("ReadFile %s: %v", name, err)
+			continue
+		}
+		if string(b) != content {
+			t.Errorf("%s: content %q, expected %q", name, b, content)
+		}
+		fi, err := dst.Stat(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !fi.ModTime().Equal(mtime) {
+			t.Errorf("%s: mtime %v, expected %v", name, fi.ModTime(), mtime)
+		}
+	}
+
+	for _, name := range []string{"/dst/moved/skip/d.txt", "/dst/moved/done/e.txt"} {
+		if _, err := dst.Stat(filepath.FromSlash(name)); !os.IsNotExist(err) {
+			t.Errorf("%s should not have been migrated", name)
+		}
+	}
+
+	b, err := ReadFile(state, checkpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(b), "\n"); lines != len(expected)+1 {
+		t.Errorf("checkpoint has %d entries, expected %d", lines, len(expected)+1)
+	}
+
+	// A second run resumes from the checkpoint and copies nothing.
+	dst = NewMemMapFs()
+	if err := Migrate(src, dst, opts); err != nil {
+		t.Fatal(err)
+	}
+	if exists, _ := Exists(dst, filepath.FromSlash("/dst")); exists {
+		t.Error("resumed migration copied files that were already done")
+	}
+}
+
+// corruptFs flips the first byte of every write, so what lands on disk
+// differs from what Migrate handed over.
+type corruptFs struct {
+	Fs
+}
+
+func (c corruptFs) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	f, err := c.Fs.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return corruptFile{f}, nil
+}
+
+type corruptFile struct {
+	File
+}
+
+func (f corruptFile) Write(p []byte) (int, error) {
+	b := append([]byte(nil), p...)
+	if len(b) > 0 {
+		b[0] ^= 0xff
+	}
+	return f.File.Write(b)
+}
+
+func TestMigrateVerifyFailure(t *testing.T) {
+	src := NewMemMapFs()
+	if err := WriteReader(src, filepath.FromSlash("/src/a.txt"), strings.NewReader("alpha")); err != nil {
+		t.Fatal(err)
+	}
+	state := NewMemMapFs()
+	checkpoint := filepath.FromSlash("/migrate.state")
+
+	opts := MigrateOptions{
+		SrcRoot:        filepath.FromSlash("/src"),
+		DstRoot:        filepath.FromSlash("/dst"),
+		CheckpointFile: checkpoint,
+		CheckpointFs:   state,
+		Verify:         true,
+	}
+	err := Migrate(src, corruptFs{NewMemMapFs()}, opts)
+	if err == nil || !strings.Contains(err.Error(), "verification") {
+		t.Fatalf("expected a verification error, got %v", err)
+	}
+
+	b, err := ReadFile(state, checkpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b) != 0 {
+		t.Errorf("checkpoint recorded a file that failed verification: %q", b)
+	}
+
+	// The retry against a healthy destination picks the file up again.
+	dst := NewMemMapFs()
+	if err := Migrate(src, dst, opts); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := ReadFile(dst, filepath.FromSlash("/dst/a.txt")); err != nil || string(b) != "alpha" {
+		t.Errorf("resumed migration: got %q, %v, expected %q", b, err, "alpha")
+	}
+}
+
+func TestRateLimitedReader(t *testing.T) {
+	l := &rateLimiter{rate: 1000, start: time.Now()}
+	r := &rateLimitedReader{r: bytes.NewReader(make([]byte, 200)), l: l}
+
+	start := time.Now()
+	n, err := io.Copy(ioutil.Discard, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 200 {
+		t.Fatalf("read %d bytes, expected 200", n)
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("reading 200 bytes at 1000 B/s took %v, expected about 200ms", elapsed)
+	}
+}
+
+func TestRateLimiterDueLargeTotal(t *testing.T) {
+	l := &rateLimiter{rate: 1 << 20, total: 1<<34 + 1<<19}
+	expected := (1<<14)*time.Second + time.Second/2
+	if due := l.due(); due != expected {
+		t.Errorf("due after %d bytes at %d B/s = %v, expected %v", l.total, l.rate, due, expected)
+	}
+}
+
+func TestMigrateExistingMode(t *testing.T) {
+	src := NewMemMapFs()
+	if err := WriteFile(src, filepath.FromSlash("/src/a.txt"), []byte("alpha"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	dst := NewMemMapFs()
+	name := filepath.FromSlash("/dst/a.txt")
+	if err := WriteFile(dst, name, []byte("old"), 0777); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := MigrateOptions{SrcRoot: filepath.FromSlash("/src"), DstRoot: filepath.FromSlash("/dst")}
+	if err := Migrate(src, dst, opts); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := dst.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0640 {
+		t.Errorf("mode %v, expected %v", fi.Mode().Perm(), os.FileMode(0640))
+	}
+}
+
+func TestMigrateRewriteNameEscape(t *testing.T) {
+	src := NewMemMapFs()
+	for _, name := range []string{"/src/a.txt", "/src/b.txt"} {
+		if err := WriteFile(src, filepath.FromSlash(name), []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rewrites := map[string]string{
+		"a.txt": filepath.FromSlash("../../a.txt"),
+		"b.txt": filepath.FromSlash("/b.txt"),
+	}
+
+	dst := NewMemMapFs()
+	opts := MigrateOptions{
+		SrcRoot:     filepath.FromSlash("/src"),
+		DstRoot:     filepath.FromSlash("/dst"),
+		RewriteName: func(name string) string { return rewrites[name] },
+	}
+	if err := Migrate(src, dst, opts); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"/dst/a.txt", "/dst/b.txt"} {
+		if _, err := dst.Stat(filepath.FromSlash(name)); err != nil {
+			t.Errorf("expected %s: %v", name, err)
+		}
+	}
+	for _, name := range []string{"/a.txt", "/b.txt"} {
+		if _, err := dst.Stat(filepath.FromSlash(name)); !os.IsNotExist(err) {
+			t.Errorf("%s was written outside DstRoot", name)
+		}
+	}
+}
+
+type closeCounter struct {
+	io.Reader
+	closed *int
+}
+
+func (c closeCounter) Close() error {
+	*c.closed++
+	return nil
+}
+
+func TestMigrateClosesTransform(t *testing.T) {
+	src, _ := setupMigrateSource(t)
+	closed := 0
+	opts := MigrateOptions{
+		SrcRoot: filepath.FromSlash("/src"),
+		DstRoot: filepath.FromSlash("/dst"),
+		Transform: func(name string, r io.Reader) (io.Reader, error) {
+			return closeCounter{r, &closed}, nil
+		},
+	}
+	if err := Migrate(src, NewMemMapFs(), opts); err != nil {
+		t.Fatal(err)
+	}
+	if closed != 7 {
+		t.Errorf("closed %d transform readers, expected 7", closed)
+	}
+}
@@ -33,6 +33,10 @@ func NewOsFs() Fs {
 
 func (OsFs) Name() string { return "OsFs" }
 
+func (OsFs) Capabilities() Features {
+	return FeatureWrite | FeatureChtimes | FeatureAtomicRename | FeatureSymlinks
+}
+
 func (OsFs) Create(name string) (File, error) {
 	f, e := os.Create(name)
 	if f == nil {
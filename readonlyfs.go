@@ -36,6 +36,11 @@ func (r *ReadOnlyFs) Name() string {
 	return "ReadOnlyFilter"
 }
 
+func (r *ReadOnlyFs) Capabilities() Features {
+	// Only features that do not modify the source survive the filter.
+	return CapabilitiesOf(r.source) & FeatureVersioning
+}
+
 func (r *ReadOnlyFs) Stat(name string) (os.FileInfo, error) {
 	return r.source.Stat(name)
 }
@@ -71,6 +71,12 @@ func (r *RegexpFs) Name() string {
 	return "RegexpFs"
 }
 
+// Capabilities reports the features of the source, except symlinks, which
+// RegexpFs does not pass through.
+func (r *RegexpFs) Capabilities() Features {
+	return CapabilitiesOf(r.source) &^ FeatureSymlinks
+}
+
 func (r *RegexpFs) Stat(name string) (os.FileInfo, error) {
 	if err := r.dirOrMatches(name); err != nil {
 		return nil, err
@@ -98,6 +98,8 @@ func (fs *Fs) Open(name string) (afero.File, error) {
 
 func (fs *Fs) Name() string { return "tarfs" }
 
+func (fs *Fs) Capabilities() afero.Features { return 0 }
+
 func (fs *Fs) Create(name string) (afero.File, error) { return nil, syscall.EROFS }
 
 func (fs *Fs) Mkdir(name string, perm os.FileMode) error { return syscall.EROFS }